@@ -15,10 +15,21 @@
 package exec
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	goerrors "errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"os"
 	"os/exec"
+	"runtime"
 	"strings"
+	"time"
+
+	"github.com/google/shlex"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/errors"
@@ -26,31 +37,64 @@ import (
 )
 
 func init() {
-	task.Register("tool/exec.Run", newExecCmd)
+	task.Register("tool/exec.Run", NewCmd(true))
 
 	// For backwards compatibility.
-	task.Register("exec", newExecCmd)
+	task.Register("exec", NewCmd(true))
 }
 
 type execCmd struct {
 	schema cue.Value
+
+	// inheritEnvDefault is the default used for the "inheritEnv" field
+	// when it is left unspecified in CUE.
+	inheritEnvDefault bool
 }
 
-func newExecCmd(v cue.Value) (task.Runner, error) {
-	return &execCmd{
-		schema: v,
-	}, nil
+// NewCmd returns a task.Runner constructor for tool/exec.Run.
+// inheritEnvDefault sets the default used for the "inheritEnv" field when
+// it is left unspecified in CUE. This package registers NewCmd(true) for
+// both "tool/exec.Run" and "exec"; NewCmd(false) gives the stricter,
+// explicit-only default instead.
+func NewCmd(inheritEnvDefault bool) func(cue.Value) (task.Runner, error) {
+	return func(v cue.Value) (task.Runner, error) {
+		return &execCmd{
+			schema:            v,
+			inheritEnvDefault: inheritEnvDefault,
+		}, nil
+	}
 }
 
 func (c *execCmd) Run(ctx *task.Context) (res interface{}, err error) {
-	cmd, doc, err := mkCommand(ctx)
+	object := ctx.Obj.Unify(c.schema)
+
+	timeout, err := lookupDuration(object.LookupPath(cue.ParsePath("timeout")))
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx := ctx.Context
+	cancel := func() {}
+	if timeout > 0 {
+		runCtx, cancel = context.WithTimeout(ctx.Context, timeout)
+	}
+	defer cancel()
+
+	newCmd, doc, err := mkCommandFactory(ctx, runCtx, c.inheritEnvDefault)
 	if err != nil {
 		return cue.Value{}, err
 	}
 
-	object := ctx.Obj.Unify(c.schema)
+	killSignal, _ := object.LookupPath(cue.ParsePath("killSignal")).String()
+	sig, err := lookupSignal(killSignal)
+	if err != nil {
+		return nil, err
+	}
+	gracePeriod, err := lookupDuration(object.LookupPath(cue.ParsePath("killGracePeriod")))
+	if err != nil {
+		return nil, err
+	}
 
-	// TODO: set environment variables, if defined.
 	stream := func(name string) (stream cue.Value, ok bool) {
 		c := object.LookupPath(cue.ParsePath(name))
 		if err := c.Null(); c.Err() != nil || err == nil {
@@ -59,25 +103,15 @@ func (c *execCmd) Run(ctx *task.Context) (res interface{}, err error) {
 		return c, true
 	}
 
-	if v, ok := stream("stdin"); !ok {
-		cmd.Stdin = ctx.Stdin
-	} else if cmd.Stdin, err = v.Reader(); err != nil {
-		return nil, errors.Wrapf(err, v.Pos(), "invalid input")
-	}
-
-	cmd.Stdout = ctx.Stdout
-	cmd.Stderr = ctx.Stderr
-
-	stdout := new(bytes.Buffer)
-	outVal, captureOut := stream("stdout")
-	if captureOut {
-		cmd.Stdout = stdout
+	ttyEnabled, ttyRows, ttyCols, err := lookupTTY(object.LookupPath(cue.ParsePath("tty")))
+	if err != nil {
+		return nil, err
 	}
 
-	stderr := new(bytes.Buffer)
-	errVal, captureErr := stream("stderr")
-	if captureErr {
-		cmd.Stderr = stderr
+	_, captureOutRequested := stream("stdout")
+	_, captureErrRequested := stream("stderr")
+	if ttyEnabled && (captureOutRequested || captureErrRequested) {
+		return nil, errors.New("tty: stdout and stderr capture are not supported when tty is set")
 	}
 
 	v := object.LookupPath(cue.ParsePath("mustSucceed"))
@@ -86,19 +120,107 @@ func (c *execCmd) Run(ctx *task.Context) (res interface{}, err error) {
 		return nil, errors.Wrapf(err, v.Pos(), "invalid bool value")
 	}
 
+	retry, err := lookupRetry(object.LookupPath(cue.ParsePath("retry")))
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr *bytes.Buffer
+	var outVal, errVal cue.Value
+	var captureOut, captureErr bool
+	var attempt, lastExitCode int
+
+	for attempt = 1; ; attempt++ {
+		cmd := newCmd()
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(sig)
+		}
+		if gracePeriod > 0 {
+			cmd.WaitDelay = gracePeriod
+		}
+
+		if !ttyEnabled {
+			if v, ok := stream("stdin"); !ok {
+				cmd.Stdin = ctx.Stdin
+			} else if cmd.Stdin, err = v.Reader(); err != nil {
+				return nil, errors.Wrapf(err, v.Pos(), "invalid input")
+			}
+
+			cmd.Stdout = ctx.Stdout
+			cmd.Stderr = ctx.Stderr
+
+			stdout = new(bytes.Buffer)
+			outVal, captureOut = stream("stdout")
+			if captureOut {
+				cmd.Stdout = stdout
+			}
+
+			stderr = new(bytes.Buffer)
+			errVal, captureErr = stream("stderr")
+			if captureErr {
+				cmd.Stderr = stderr
+			}
+		}
+
+		if ttyEnabled {
+			err = runTTY(cmd, ctx, ttyRows, ttyCols)
+		} else {
+			err = cmd.Run()
+		}
+		lastExitCode = exitCode(err)
+
+		if err == nil || attempt >= retry.attempts || !retry.shouldRetry(lastExitCode) {
+			break
+		}
+
+		select {
+		case <-time.After(retry.nextDelay(attempt)):
+		case <-runCtx.Done():
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+	}
+
 	update := map[string]interface{}{}
-	err = cmd.Run()
 	update["success"] = err == nil
+	update["attempts"] = attempt
+	update["lastExitCode"] = lastExitCode
+
+	timedOut := timeout > 0 && runCtx.Err() == context.DeadlineExceeded
+	update["timedOut"] = timedOut
+	if timedOut {
+		// Report the user-configured name ("SIGTERM"), not
+		// syscall.Signal.String()'s human text ("terminated"), so CUE
+		// workflows can compare signal against killSignal.
+		update["signal"] = killSignal
+	}
+
+	var truncated bool
 
 	if captureOut {
-		update["stdout"] = toStreamOutput(outVal, stdout)
+		codec, _ := object.LookupPath(cue.ParsePath("stdoutCodec")).String()
+		var t bool
+		update["stdout"], t = toStreamOutput(outVal, stdout, codec)
+		truncated = truncated || t
 	}
 
 	if captureErr {
 		if stderr.Len() == 0 && err != nil {
 			_, _ = stderr.WriteString(err.Error())
 		}
-		update["stderr"] = toStreamOutput(errVal, stderr)
+		codec, _ := object.LookupPath(cue.ParsePath("stderrCodec")).String()
+		var t bool
+		update["stderr"], t = toStreamOutput(errVal, stderr, codec)
+		truncated = truncated || t
+	}
+
+	if truncated {
+		update["truncated"] = true
+		if err == nil {
+			err = fmt.Errorf("command %q: a line of stdout or stderr exceeded the scan buffer and was dropped", doc)
+			update["success"] = false
+		}
 	}
 
 	if err == nil {
@@ -113,22 +235,168 @@ func (c *execCmd) Run(ctx *task.Context) (res interface{}, err error) {
 
 }
 
-// toStreamOutput converts a value to a string or bytes, depending on the
-// kind of the v. If multiple types are specified, it defaults to string.
-func toStreamOutput(v cue.Value, buf *bytes.Buffer) any {
+// exitCode reports the process exit code for err, or -1 if it could not be
+// determined (for example, because the command never started).
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if goerrors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// retryPolicy is the resolved form of the execCmd schema's "retry" field.
+type retryPolicy struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	multiplier float64
+	jitter     bool
+	retryAny   bool
+	retryOn    map[int]bool
+}
+
+// lookupRetry reads the "retry" substruct of the execCmd schema.
+func lookupRetry(v cue.Value) (retryPolicy, error) {
+	p := retryPolicy{attempts: 1, multiplier: 1}
+
+	if n, err := v.LookupPath(cue.ParsePath("attempts")).Int64(); err == nil && n > 0 {
+		p.attempts = int(n)
+	}
+
+	var err error
+	if p.backoff, err = lookupDuration(v.LookupPath(cue.ParsePath("backoff"))); err != nil {
+		return p, err
+	}
+	if p.maxBackoff, err = lookupDuration(v.LookupPath(cue.ParsePath("maxBackoff"))); err != nil {
+		return p, err
+	}
+	if m, err := v.LookupPath(cue.ParsePath("multiplier")).Float64(); err == nil {
+		p.multiplier = m
+	}
+	if j, err := v.LookupPath(cue.ParsePath("jitter")).Bool(); err == nil {
+		p.jitter = j
+	}
+
+	retryOn := v.LookupPath(cue.ParsePath("retryOn"))
+	if s, err := retryOn.String(); err == nil {
+		p.retryAny = s == "any"
+	} else {
+		p.retryOn = map[int]bool{}
+		for iter, _ := retryOn.List(); iter.Next(); {
+			n, err := iter.Value().Int64()
+			if err != nil {
+				return p, errors.Wrapf(err, iter.Value().Pos(), "invalid retryOn entry")
+			}
+			p.retryOn[int(n)] = true
+		}
+	}
+
+	return p, nil
+}
+
+// shouldRetry reports whether a command that exited with exitCode should be
+// retried under p.
+func (p retryPolicy) shouldRetry(exitCode int) bool {
+	if exitCode == 0 {
+		return false
+	}
+	if p.retryAny {
+		return true
+	}
+	return p.retryOn[exitCode]
+}
+
+// nextDelay computes the delay before the given 1-indexed retry attempt,
+// applying the configured multiplier, cap, and optional full jitter.
+func (p retryPolicy) nextDelay(attempt int) time.Duration {
+	d := float64(p.backoff) * math.Pow(p.multiplier, float64(attempt-1))
+	if p.maxBackoff > 0 && d > float64(p.maxBackoff) {
+		d = float64(p.maxBackoff)
+	}
+	if p.jitter {
+		d *= rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// toStreamOutput converts a value to a string, bytes, or a list of lines,
+// depending on the kind of v. If v allows more than one of these kinds, it
+// returns nil. truncated reports whether a line exceeded the scan buffer and
+// had to be dropped; it is always false for the string/bytes forms, since
+// those return the buffer unmodified.
+func toStreamOutput(v cue.Value, buf *bytes.Buffer, codec string) (out any, truncated bool) {
 	switch v.IncompleteKind() {
 	case cue.StringKind:
-		return buf.String()
+		return buf.String(), false
 	case cue.BytesKind:
-		return buf.Bytes()
+		return buf.Bytes(), false
+	case cue.ListKind:
+		// codec only applies to the [...{...}] struct element form; a
+		// stream declared [...string] must come out as plain strings, or
+		// a line that happens to parse as JSON would silently change
+		// type to a number, bool, or object.
+		elem := v.LookupPath(cue.MakePath(cue.AnyIndex))
+		if elem.IncompleteKind() != cue.StructKind {
+			codec = ""
+		}
+		return toLines(buf, codec)
 	}
-	return nil
+	return nil, false
 }
 
-func mkCommand(ctx *task.Context) (c *exec.Cmd, doc string, err error) {
+// toLines splits buf into one element per line, for the [...string] and
+// [...{...}] forms of stdout/stderr, reshaping output that was fully
+// buffered before the command exited. When codec is set, each line is
+// additionally decoded as JSON so it can unify against a struct element
+// schema, modeled on how BuildKit's jsonmessage decoder turns a byte
+// stream into structured events (e.g. for kaniko, buildctl, or kubectl).
+// truncated is true if a line exceeded maxLineSize and the scan stopped
+// early, so the reported lines are a prefix of the full output rather than
+// all of it.
+func toLines(buf *bytes.Buffer, codec string) (lines []interface{}, truncated bool) {
+	const maxLineSize = 16 * 1024 * 1024
+
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		switch codec {
+		case "json", "jsonl", "ndjson":
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+				lines = append(lines, line)
+				continue
+			}
+			lines = append(lines, decoded)
+		default:
+			lines = append(lines, line)
+		}
+	}
+	if scanner.Err() != nil {
+		truncated = true
+	}
+	return lines, truncated
+}
+
+// mkCommandFactory resolves the command line, working directory, and
+// environment from ctx, and returns a factory that builds a fresh
+// *exec.Cmd for each attempt, since an exec.Cmd can only be run once.
+func mkCommandFactory(ctx *task.Context, runCtx context.Context, inheritEnvDefault bool) (factory func() *exec.Cmd, doc string, err error) {
 	var bin string
 	var args []string
 
+	sh, useShell, err := lookupShell(ctx.Obj.LookupPath(cue.ParsePath("shell")))
+	if err != nil {
+		return nil, "", err
+	}
+
 	v := ctx.Lookup("cmd")
 	if ctx.Err != nil {
 		return nil, "", ctx.Err
@@ -138,52 +406,74 @@ func mkCommand(ctx *task.Context) (c *exec.Cmd, doc string, err error) {
 	case cue.StringKind:
 		str := ctx.String("cmd")
 		doc = str
-		list := strings.Fields(str)
-		bin = list[0]
-		args = append(args, list[1:]...)
+		if useShell {
+			bin = sh.bin
+			args = append(args, sh.args...)
+			args = append(args, str)
+		} else {
+			list, err := shlex.Split(str)
+			if err != nil {
+				return nil, "", errors.Wrapf(err, v.Pos(), "invalid command %q", str)
+			}
+			if len(list) == 0 {
+				return nil, "", errors.New("empty command")
+			}
+			bin = list[0]
+			args = append(args, list[1:]...)
+		}
 
 	case cue.ListKind:
 		list, _ := v.List()
 		if !list.Next() {
 			return nil, "", errors.New("empty command list")
 		}
-		bin, err = list.Value().String()
+		first, err := list.Value().String()
 		if err != nil {
 			return nil, "", err
 		}
-		doc += bin
+		elems := []string{first}
+		doc = first
 		for list.Next() {
 			str, err := list.Value().String()
 			if err != nil {
 				return nil, "", err
 			}
-			args = append(args, str)
+			elems = append(elems, str)
 			doc += " " + str
 		}
+		if useShell {
+			// Joining list elements with a single space and handing the
+			// result to a shell would re-split any element containing
+			// whitespace, corrupting exactly the arguments list-form cmd
+			// exists to protect. shell only applies to string-form cmd.
+			return nil, "", errors.New("shell is not supported with list-form cmd; use a single string")
+		}
+		bin = elems[0]
+		args = elems[1:]
 	}
 
 	if bin == "" {
 		return nil, "", errors.New("empty command")
 	}
 
-	cmd := exec.CommandContext(ctx.Context, bin, args...)
+	dir, _ := ctx.Obj.LookupPath(cue.ParsePath("dir")).String()
 
-	cmd.Dir, _ = ctx.Obj.LookupPath(cue.ParsePath("dir")).String()
+	var userEnv []string
 
-	env := ctx.Obj.LookupPath(cue.ParsePath("env"))
+	envVal := ctx.Obj.LookupPath(cue.ParsePath("env"))
 	// List case.
-	for iter, _ := env.List(); iter.Next(); {
+	for iter, _ := envVal.List(); iter.Next(); {
 		v, _ := iter.Value().Default()
 		str, err := v.String()
 		if err != nil {
 			return nil, "", errors.Wrapf(err, v.Pos(),
 				"invalid environment variable value %q", v)
 		}
-		cmd.Env = append(cmd.Env, str)
+		userEnv = append(userEnv, str)
 	}
 
 	// Struct case.
-	for iter, _ := env.Fields(); iter.Next(); {
+	for iter, _ := envVal.Fields(); iter.Next(); {
 		label := iter.Label()
 		v, _ := iter.Value().Default()
 		var str string
@@ -196,8 +486,166 @@ func mkCommand(ctx *task.Context) (c *exec.Cmd, doc string, err error) {
 			return nil, "", errors.Newf(v.Pos(),
 				"invalid environment variable value %q", v)
 		}
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", label, str))
+		userEnv = append(userEnv, fmt.Sprintf("%s=%s", label, str))
+	}
+
+	inheritEnv := inheritEnvDefault
+	if v := ctx.Obj.LookupPath(cue.ParsePath("inheritEnv")); v.Exists() {
+		if b, err := v.Bool(); err == nil {
+			inheritEnv = b
+		}
+	}
+
+	var base []string
+	if inheritEnv {
+		base = os.Environ()
+	} else {
+		passEnv := ctx.Obj.LookupPath(cue.ParsePath("passEnv"))
+		for iter, _ := passEnv.List(); iter.Next(); {
+			name, err := iter.Value().String()
+			if err != nil {
+				return nil, "", errors.Wrapf(err, iter.Value().Pos(),
+					"invalid passEnv entry %q", iter.Value())
+			}
+			if val, ok := os.LookupEnv(name); ok {
+				base = append(base, name+"="+val)
+			}
+		}
+	}
+
+	env := dedupEnv(append(base, userEnv...))
+
+	factory = func() *exec.Cmd {
+		cmd := exec.CommandContext(runCtx, bin, args...)
+		cmd.Dir = dir
+		cmd.Env = env
+		return cmd
+	}
+
+	return factory, doc, nil
+}
+
+// dedupEnv removes duplicate keys from env, keeping the last value for each
+// key but the position of its first occurrence, mirroring the behavior
+// os/exec settled on for Cmd.Environ. Keys are compared case-insensitively
+// on Windows and case-sensitively elsewhere; Windows' leading-"=" keys
+// (e.g. "=C:") are treated as distinct per-drive entries rather than
+// collapsed together.
+func dedupEnv(env []string) []string {
+	index := make(map[string]int, len(env))
+	result := make([]string, 0, len(env))
+	for _, entry := range env {
+		key := envKey(entry)
+		if runtime.GOOS == "windows" {
+			key = strings.ToUpper(key)
+		}
+		if i, ok := index[key]; ok {
+			result[i] = entry
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, entry)
+	}
+	return result
+}
+
+// envKey extracts the deduplication key from a KEY=VALUE environment entry.
+func envKey(entry string) string {
+	if strings.HasPrefix(entry, "=") {
+		if i := strings.Index(entry[1:], "="); i >= 0 {
+			return entry[:i+1]
+		}
+		return entry
+	}
+	if i := strings.IndexByte(entry, '='); i >= 0 {
+		return entry[:i]
+	}
+	return entry
+}
+
+// lookupDuration reads an optional duration field, such as timeout or
+// killGracePeriod, returning 0 when the field is unset (null).
+func lookupDuration(v cue.Value) (time.Duration, error) {
+	if err := v.Null(); err == nil {
+		return 0, nil
+	}
+	str, err := v.String()
+	if err != nil {
+		return 0, errors.Wrapf(err, v.Pos(), "invalid duration value")
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, errors.Wrapf(err, v.Pos(), "invalid duration %q", str)
+	}
+	return d, nil
+}
+
+// lookupTTY reads the "tty" field of the execCmd schema, which is either a
+// bool or a struct with explicit rows/cols.
+func lookupTTY(v cue.Value) (enabled bool, rows, cols uint16, err error) {
+	switch v.IncompleteKind() {
+	case cue.BoolKind:
+		enabled, _ = v.Bool()
+		return enabled, 0, 0, nil
+	case cue.StructKind:
+		r := v.LookupPath(cue.ParsePath("rows"))
+		ri, err := r.Uint64()
+		if err != nil {
+			return false, 0, 0, errors.Wrapf(err, r.Pos(), "invalid tty.rows")
+		}
+		c := v.LookupPath(cue.ParsePath("cols"))
+		ci, err := c.Uint64()
+		if err != nil {
+			return false, 0, 0, errors.Wrapf(err, c.Pos(), "invalid tty.cols")
+		}
+		return true, uint16(ri), uint16(ci), nil
+	}
+	return false, 0, 0, nil
+}
+
+// shellInterpreter holds the binary and leading arguments used to invoke a
+// command string through a shell.
+type shellInterpreter struct {
+	bin  string
+	args []string
+}
+
+// lookupShell reports the interpreter to use for the "shell" field of the
+// execCmd schema. ok is false when shell is unset or false, in which case
+// cmd is tokenized and executed directly.
+func lookupShell(v cue.Value) (sh shellInterpreter, ok bool, err error) {
+	if v.IncompleteKind() == cue.BoolKind {
+		enabled, err := v.Bool()
+		if err != nil || !enabled {
+			return shellInterpreter{}, false, nil
+		}
+		return defaultShell(), true, nil
 	}
 
-	return cmd, doc, nil
+	name, err := v.String()
+	if err != nil {
+		// shell is unset: fall back to the non-shell tokenizer.
+		return shellInterpreter{}, false, nil
+	}
+	return namedShell(name), true, nil
+}
+
+// defaultShell returns the platform's default interpreter for shell: true.
+func defaultShell() shellInterpreter {
+	if runtime.GOOS == "windows" {
+		return shellInterpreter{bin: "cmd", args: []string{"/C"}}
+	}
+	return shellInterpreter{bin: "sh", args: []string{"-c"}}
+}
+
+// namedShell returns the interpreter for an explicitly named shell, such as
+// "bash", "sh", or "pwsh".
+func namedShell(name string) shellInterpreter {
+	if runtime.GOOS == "windows" && name == "cmd" {
+		return shellInterpreter{bin: "cmd", args: []string{"/C"}}
+	}
+	if name == "pwsh" || name == "powershell" {
+		return shellInterpreter{bin: name, args: []string{"-Command"}}
+	}
+	return shellInterpreter{bin: name, args: []string{"-c"}}
 }
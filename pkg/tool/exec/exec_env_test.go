@@ -0,0 +1,71 @@
+// Copyright 2019 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDedupEnv(t *testing.T) {
+	testCases := []struct {
+		name string
+		env  []string
+		want []string
+	}{
+		{
+			name: "no duplicates",
+			env:  []string{"A=1", "B=2"},
+			want: []string{"A=1", "B=2"},
+		},
+		{
+			name: "later value wins at earlier position",
+			env:  []string{"PATH=/usr/bin", "HOME=/root", "PATH=/custom/bin"},
+			want: []string{"PATH=/custom/bin", "HOME=/root"},
+		},
+		{
+			name: "empty value still overrides",
+			env:  []string{"FOO=bar", "FOO="},
+			want: []string{"FOO="},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := dedupEnv(tc.env)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("dedupEnv(%v) = %v, want %v", tc.env, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnvKey(t *testing.T) {
+	testCases := []struct {
+		entry string
+		want  string
+	}{
+		{entry: "PATH=/usr/bin", want: "PATH"},
+		{entry: "FOO=", want: "FOO"},
+		{entry: "NO_EQUALS", want: "NO_EQUALS"},
+		{entry: "=C:=C:\\Users\\me", want: "=C:"},
+	}
+
+	for _, tc := range testCases {
+		if got := envKey(tc.entry); got != tc.want {
+			t.Errorf("envKey(%q) = %q, want %q", tc.entry, got, tc.want)
+		}
+	}
+}
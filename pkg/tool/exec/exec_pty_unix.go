@@ -0,0 +1,69 @@
+// Copyright 2019 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package exec
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/creack/pty"
+	"golang.org/x/term"
+
+	"cuelang.org/go/internal/task"
+)
+
+// runTTY starts cmd attached to a new pseudo-terminal, forwarding
+// ctx.Stdin/ctx.Stdout to it, switching the host terminal to raw mode when
+// it is one, and propagating SIGWINCH resizes, then waits for cmd to exit.
+func runTTY(cmd *exec.Cmd, ctx *task.Context, rows, cols uint16) error {
+	var size *pty.Winsize
+	if rows > 0 || cols > 0 {
+		size = &pty.Winsize{Rows: rows, Cols: cols}
+	}
+
+	ptmx, err := pty.StartWithSize(cmd, size)
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	if f, ok := ctx.Stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		if size == nil {
+			_ = pty.InheritSize(f, ptmx)
+		}
+		if oldState, err := term.MakeRaw(int(f.Fd())); err == nil {
+			defer func() { _ = term.Restore(int(f.Fd()), oldState) }()
+		}
+
+		winch := make(chan os.Signal, 1)
+		signal.Notify(winch, syscall.SIGWINCH)
+		defer signal.Stop(winch)
+		go func() {
+			for range winch {
+				_ = pty.InheritSize(f, ptmx)
+			}
+		}()
+	}
+
+	go func() { _, _ = io.Copy(ptmx, ctx.Stdin) }()
+	_, _ = io.Copy(ctx.Stdout, ptmx)
+
+	return cmd.Wait()
+}
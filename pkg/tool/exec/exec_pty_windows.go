@@ -0,0 +1,30 @@
+// Copyright 2019 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package exec
+
+import (
+	"fmt"
+	"os/exec"
+
+	"cuelang.org/go/internal/task"
+)
+
+// runTTY is not yet supported on Windows: doing so requires driving the
+// ConPTY API, which this package does not wire up.
+func runTTY(cmd *exec.Cmd, ctx *task.Context, rows, cols uint16) error {
+	return fmt.Errorf("tty: not supported on windows")
+}
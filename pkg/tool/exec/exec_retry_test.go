@@ -0,0 +1,98 @@
+// Copyright 2019 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyNextDelay(t *testing.T) {
+	p := retryPolicy{
+		backoff:    time.Second,
+		maxBackoff: 5 * time.Second,
+		multiplier: 2,
+	}
+
+	testCases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: 2 * time.Second},
+		{attempt: 3, want: 4 * time.Second},
+		{attempt: 4, want: 5 * time.Second}, // capped by maxBackoff
+		{attempt: 5, want: 5 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		if got := p.nextDelay(tc.attempt); got != tc.want {
+			t.Errorf("nextDelay(%d) = %v, want %v", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicyNextDelayUncapped(t *testing.T) {
+	p := retryPolicy{
+		backoff:    time.Second,
+		maxBackoff: 0, // 0 means unbounded
+		multiplier: 2,
+	}
+
+	got := p.nextDelay(10)
+	want := time.Second * time.Duration(1<<9)
+	if got != want {
+		t.Errorf("nextDelay(10) = %v, want %v", got, want)
+	}
+}
+
+func TestRetryPolicyNextDelayJitter(t *testing.T) {
+	p := retryPolicy{
+		backoff:    time.Second,
+		maxBackoff: time.Second,
+		multiplier: 1,
+		jitter:     true,
+	}
+
+	for i := 0; i < 100; i++ {
+		d := p.nextDelay(1)
+		if d < 0 || d > time.Second {
+			t.Fatalf("nextDelay with jitter = %v, want in [0, 1s]", d)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	testCases := []struct {
+		name string
+		p    retryPolicy
+		code int
+		want bool
+	}{
+		{name: "success never retries", p: retryPolicy{retryAny: true}, code: 0, want: false},
+		{name: "any retries on failure", p: retryPolicy{retryAny: true}, code: 1, want: true},
+		{name: "retryOn match", p: retryPolicy{retryOn: map[int]bool{2: true}}, code: 2, want: true},
+		{name: "retryOn no match", p: retryPolicy{retryOn: map[int]bool{2: true}}, code: 3, want: false},
+		{name: "no retryOn configured", p: retryPolicy{}, code: 1, want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.p.shouldRetry(tc.code); got != tc.want {
+				t.Errorf("shouldRetry(%d) = %v, want %v", tc.code, got, tc.want)
+			}
+		})
+	}
+}
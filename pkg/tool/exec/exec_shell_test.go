@@ -0,0 +1,100 @@
+// Copyright 2019 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exec
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/shlex"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/internal/task"
+)
+
+// compile is a cuecontext.New().CompileString stand-in that doesn't pull in
+// cuelang.org/go/pkg, which registers this package's own builtin and would
+// create an import cycle from inside pkg/tool/exec's own tests.
+func compile(t *testing.T, src string) cue.Value {
+	t.Helper()
+	var r cue.Runtime
+	inst, err := r.Compile(t.Name(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return inst.Value()
+}
+
+func TestLookupShell(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  string
+		ok   bool
+		sh   shellInterpreter
+	}{
+		{name: "unset", src: "_", ok: false},
+		{name: "false", src: "false", ok: false},
+		{name: "true", src: "true", ok: true, sh: defaultShell()},
+		{name: "bash", src: `"bash"`, ok: true, sh: shellInterpreter{bin: "bash", args: []string{"-c"}}},
+		{name: "pwsh", src: `"pwsh"`, ok: true, sh: shellInterpreter{bin: "pwsh", args: []string{"-Command"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := compile(t, tc.src)
+			sh, ok, err := lookupShell(v)
+			if err != nil {
+				t.Fatalf("lookupShell: %v", err)
+			}
+			if ok != tc.ok {
+				t.Fatalf("ok = %v, want %v", ok, tc.ok)
+			}
+			if ok && !reflect.DeepEqual(sh, tc.sh) {
+				t.Errorf("got %+v, want %+v", sh, tc.sh)
+			}
+		})
+	}
+}
+
+// TestMkCommandFactoryRejectsListFormWithShell guards against silently
+// rejoining a list-form cmd with spaces and handing it to a shell, which
+// would re-split any element containing embedded whitespace.
+func TestMkCommandFactoryRejectsListFormWithShell(t *testing.T) {
+	v := compile(t, `{
+		cmd: ["echo", "hello world"]
+		shell: true
+	}`)
+
+	ctx := &task.Context{Context: context.Background(), Obj: v}
+	_, _, err := mkCommandFactory(ctx, ctx.Context, true)
+	if err == nil {
+		t.Fatal("mkCommandFactory: got nil error, want rejection of shell with list-form cmd")
+	}
+}
+
+// TestShlexSplit pins down the quoting behavior string-form cmd relies on
+// when shell is unset: unlike strings.Fields, quoted substrings containing
+// spaces stay together as a single argument.
+func TestShlexSplit(t *testing.T) {
+	got, err := shlex.Split(`grep 'hello world' file`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"grep", "hello world", "file"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
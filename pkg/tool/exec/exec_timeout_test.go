@@ -0,0 +1,85 @@
+// Copyright 2019 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package exec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cuelang.org/go/internal/task"
+)
+
+// TestRunForceKillsProcessIgnoringSignal runs a command that traps and
+// ignores killSignal, and checks that killGracePeriod still bounds Run: the
+// process is force-killed rather than hanging forever, and the reported
+// update reflects the timeout and the signal that was sent.
+func TestRunForceKillsProcessIgnoringSignal(t *testing.T) {
+	v := compile(t, `{
+		cmd: ["sh", "-c", "trap '' TERM; sleep 5"]
+		timeout: "50ms"
+		killSignal: "SIGTERM"
+		killGracePeriod: "50ms"
+		mustSucceed: false
+		dir: "."
+		env: {}
+		inheritEnv: true
+		passEnv: []
+		retry: {
+			attempts:   1
+			backoff:    "0s"
+			maxBackoff: "0s"
+			multiplier: 2.0
+			jitter:     false
+			retryOn:    "any"
+		}
+	}`)
+
+	c := &execCmd{}
+	ctx := &task.Context{Context: context.Background(), Obj: v}
+
+	done := make(chan struct{})
+	var res interface{}
+	var err error
+	go func() {
+		res, err = c.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return: killGracePeriod failed to bound the command")
+	}
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	update, ok := res.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Run result = %#v, want map[string]interface{}", res)
+	}
+	if update["success"] != false {
+		t.Errorf("success = %v, want false", update["success"])
+	}
+	if update["timedOut"] != true {
+		t.Errorf("timedOut = %v, want true", update["timedOut"])
+	}
+	if update["signal"] != "SIGTERM" {
+		t.Errorf("signal = %v, want SIGTERM", update["signal"])
+	}
+}
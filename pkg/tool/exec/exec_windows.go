@@ -0,0 +1,27 @@
+// Copyright 2019 CUE Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package exec
+
+import "os"
+
+// lookupSignal translates a POSIX signal name to the closest Windows
+// equivalent. Windows processes cannot be asked to terminate gracefully
+// through (*os.Process).Signal, so every name maps to os.Kill, which the
+// os package implements by calling TerminateProcess.
+func lookupSignal(name string) (os.Signal, error) {
+	return os.Kill, nil
+}